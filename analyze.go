@@ -0,0 +1,77 @@
+package borges
+
+import (
+	"fmt"
+	"os"
+
+	siva "gopkg.in/src-d/go-siva.v1"
+)
+
+// FileAnalysis describes the structural makeup of a single siva file.
+type FileAnalysis struct {
+	Path             string
+	Segments         int
+	IndexEntries     int
+	ObjectCount      int
+	UncompressedSize int64
+	CompressedSize   int64
+}
+
+// Analyze walks the block index of the siva file at path and reports its
+// structural makeup.
+func Analyze(path string) (*FileAnalysis, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := siva.NewReader(f).Index()
+	if err != nil {
+		return nil, fmt.Errorf("reading siva index: %s", err)
+	}
+
+	var objects int
+	var uncompressed int64
+	var segments int
+	var prevStart uint64
+
+	for i, e := range index {
+		// index is sorted by absolute position; Start is relative to the
+		// append block and resets to 0 at the start of each new block, so a
+		// new, non-increasing Start marks a block boundary.
+		if i == 0 || e.Start <= prevStart {
+			segments++
+		}
+		prevStart = e.Start
+
+		uncompressed += int64(e.Size)
+
+		if !e.Mode.IsDir() {
+			objects++
+		}
+	}
+
+	return &FileAnalysis{
+		Path:             path,
+		Segments:         segments,
+		IndexEntries:     len(index),
+		ObjectCount:      objects,
+		UncompressedSize: uncompressed,
+		CompressedSize:   info.Size(),
+	}, nil
+}
+
+func totalSegments(analyses []*FileAnalysis) int {
+	var total int
+	for _, a := range analyses {
+		total += a.Segments
+	}
+
+	return total
+}
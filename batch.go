@@ -0,0 +1,349 @@
+package borges
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/tomb.v2"
+)
+
+// killGrace is how long a worker waits after sending SIGTERM to an in-flight
+// pack executor before escalating to SIGKILL.
+const killGrace = 30 * time.Second
+
+// Kill terminates the pack's in-flight process, if any, by sending SIGTERM
+// and then, if it is still running after grace, SIGKILL. Safe to call
+// concurrently with, or before, Run: if Run hasn't started the process yet,
+// it kills it immediately once it does.
+func (p *Pack) Kill(grace time.Duration) {
+	p.mu.Lock()
+	p.killed = true
+	proc, done := p.proc, p.done
+	p.mu.Unlock()
+
+	if proc == nil {
+		return
+	}
+
+	_ = proc.Signal(syscall.SIGTERM)
+
+	time.AfterFunc(grace, func() {
+		select {
+		case <-done:
+			// the process already exited; its PID may have been recycled.
+			return
+		default:
+			_ = proc.Signal(syscall.SIGKILL)
+		}
+	})
+}
+
+// RepoResult is the outcome of packing a single repository as part of a
+// PackBatch.
+type RepoResult struct {
+	Repo   string
+	Result *PackResult
+	Err    error
+}
+
+// PackBatch runs Pack against several repositories concurrently. Workers are
+// supervised by a tomb.Tomb (as restic's archiver supervises its workers):
+// if any worker fails, the tomb enters a dying state and every other worker
+// kills its in-flight executor before returning.
+type PackBatch struct {
+	binary      string
+	repos       []string
+	concurrency int
+}
+
+// NewPackBatch creates a PackBatch that packs each of repos, running at most
+// concurrency pack runs at a time.
+func NewPackBatch(binary string, repos []string, concurrency int) (*PackBatch, error) {
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no repositories given")
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &PackBatch{
+		binary:      binary,
+		repos:       repos,
+		concurrency: concurrency,
+	}, nil
+}
+
+// Run packs every repository in the batch. Each worker calls NewPack for
+// itself, so every pack run gets its own temp list file and
+// --root-repositories-dir and workers never collide. If any worker's pack
+// run fails, the batch is killed and every other in-flight executor is
+// terminated before Run returns.
+func (b *PackBatch) Run() (*BatchResult, error) {
+	jobs := make(chan string, len(b.repos))
+	for _, repo := range b.repos {
+		jobs <- repo
+	}
+	close(jobs)
+
+	var (
+		mu      sync.Mutex
+		results []RepoResult
+	)
+
+	var t tomb.Tomb
+	for i := 0; i < b.concurrency; i++ {
+		t.Go(func() error {
+			return b.worker(&t, jobs, &mu, &results)
+		})
+	}
+
+	runErr := t.Wait()
+
+	return newBatchResult(results), runErr
+}
+
+func (b *PackBatch) worker(t *tomb.Tomb, jobs <-chan string, mu *sync.Mutex, results *[]RepoResult) error {
+	for {
+		select {
+		case <-t.Dying():
+			return nil
+		case repo, ok := <-jobs:
+			if !ok {
+				return nil
+			}
+
+			result, err := b.packOne(t, repo)
+			if err == tomb.ErrDying {
+				return nil
+			}
+
+			mu.Lock()
+			*results = append(*results, RepoResult{Repo: repo, Result: result, Err: err})
+			mu.Unlock()
+
+			if err != nil {
+				return fmt.Errorf("%s: %s", repo, err)
+			}
+		}
+	}
+}
+
+func (b *PackBatch) packOne(t *tomb.Tomb, repo string) (*PackResult, error) {
+	select {
+	case <-t.Dying():
+		return nil, tomb.ErrDying
+	default:
+	}
+
+	pack, err := NewPack(b.binary, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+
+	go func() {
+		select {
+		case <-t.Dying():
+			pack.Kill(killGrace)
+		case <-watchDone:
+		}
+	}()
+
+	if err := pack.Run(); err != nil {
+		return nil, err
+	}
+
+	return pack.Result()
+}
+
+// Stat summarizes a set of samples across a batch.
+type Stat struct {
+	Min  float64
+	Max  float64
+	Mean float64
+	P95  float64
+}
+
+func computeStat(values []float64) Stat {
+	if len(values) == 0 {
+		return Stat{}
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+
+	idx := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+
+	return Stat{
+		Min:  sorted[0],
+		Max:  sorted[len(sorted)-1],
+		Mean: sum / float64(len(sorted)),
+		P95:  sorted[idx],
+	}
+}
+
+// BatchAggregate holds cross-repository statistics for a PackBatch run.
+type BatchAggregate struct {
+	Memory   Stat
+	Wtime    Stat
+	FileSize Stat
+}
+
+// BatchResult is the aggregated outcome of a PackBatch run.
+type BatchResult struct {
+	Repos     []RepoResult
+	Aggregate BatchAggregate
+}
+
+func newBatchResult(repos []RepoResult) *BatchResult {
+	var memory, wtime, fileSize []float64
+
+	for _, r := range repos {
+		if r.Result == nil {
+			continue
+		}
+
+		memory = append(memory, float64(r.Result.Memory))
+		wtime = append(wtime, r.Result.Wtime.Seconds())
+		fileSize = append(fileSize, float64(r.Result.FileSize))
+	}
+
+	return &BatchResult{
+		Repos: repos,
+		Aggregate: BatchAggregate{
+			Memory:   computeStat(memory),
+			Wtime:    computeStat(wtime),
+			FileSize: computeStat(fileSize),
+		},
+	}
+}
+
+const BatchSeries = "batch"
+
+// WriteCSV writes one row per repository plus four trailing aggregate rows
+// (min, max, mean and p95) across the batch.
+func (b *BatchResult) WriteCSV(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "Repo,Memory,Wtime,FileSize,Error\n"); err != nil {
+		return err
+	}
+
+	for _, r := range b.Repos {
+		if r.Result == nil {
+			if _, err := fmt.Fprintf(w, "%s,,,,%s\n", r.Repo, r.Err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := fmt.Fprintf(w, "%s,%f,%f,%f,\n",
+			r.Repo, toMiB(r.Result.Memory), r.Result.Wtime.Seconds(), toMiB(r.Result.FileSize)); err != nil {
+			return err
+		}
+	}
+
+	a := b.Aggregate
+	_, err := fmt.Fprintf(w, "min,%f,%f,%f,\nmax,%f,%f,%f,\nmean,%f,%f,%f,\np95,%f,%f,%f,\n",
+		toMiB(int64(a.Memory.Min)), a.Wtime.Min, toMiB(int64(a.FileSize.Min)),
+		toMiB(int64(a.Memory.Max)), a.Wtime.Max, toMiB(int64(a.FileSize.Max)),
+		toMiB(int64(a.Memory.Mean)), a.Wtime.Mean, toMiB(int64(a.FileSize.Mean)),
+		toMiB(int64(a.Memory.P95)), a.Wtime.P95, toMiB(int64(a.FileSize.P95)),
+	)
+	return err
+}
+
+// SaveCSV writes the batch CSV report to path.
+func (b *BatchResult) SaveCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := b.WriteCSV(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// repoResultJSON is the on-disk JSON representation of a single RepoResult.
+type repoResultJSON struct {
+	Repo  string          `json:"repo"`
+	Error string          `json:"error,omitempty"`
+	Stats *packResultJSON `json:"stats,omitempty"`
+}
+
+// batchResultJSON is the on-disk JSON representation of a BatchResult.
+type batchResultJSON struct {
+	Version  int              `json:"version"`
+	Repos    []repoResultJSON `json:"repos"`
+	Memory   Stat             `json:"memory"`
+	Wtime    Stat             `json:"wtime"`
+	FileSize Stat             `json:"file_size"`
+}
+
+func (b *BatchResult) toJSON() batchResultJSON {
+	repos := make([]repoResultJSON, 0, len(b.Repos))
+	for _, r := range b.Repos {
+		rr := repoResultJSON{Repo: r.Repo}
+		if r.Err != nil {
+			rr.Error = r.Err.Error()
+		}
+		if r.Result != nil {
+			stats := r.Result.toJSON()
+			rr.Stats = &stats
+		}
+		repos = append(repos, rr)
+	}
+
+	return batchResultJSON{
+		Version:  packResultVersion,
+		Repos:    repos,
+		Memory:   b.Aggregate.Memory,
+		Wtime:    b.Aggregate.Wtime,
+		FileSize: b.Aggregate.FileSize,
+	}
+}
+
+// WriteJSON writes a versioned JSON document describing the batch result to w.
+func (b *BatchResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b.toJSON())
+}
+
+// SaveJSON writes the JSON document for the batch result to path.
+func (b *BatchResult) SaveJSON(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := b.WriteJSON(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
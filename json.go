@@ -0,0 +1,83 @@
+package borges
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dustin/go-humanize"
+)
+
+const packResultVersion = 1
+
+// packResultJSON is the on-disk JSON representation of a PackResult.
+type packResultJSON struct {
+	Version int `json:"version"`
+
+	MemoryBytes int64  `json:"memory_bytes"`
+	MemoryHuman string `json:"memory_human"`
+
+	Wtime string `json:"wtime"`
+	Stime string `json:"stime"`
+	Utime string `json:"utime"`
+
+	FileSizeBytes int64  `json:"file_size_bytes"`
+	FileSizeHuman string `json:"file_size_human"`
+
+	Files    int `json:"files"`
+	Segments int `json:"segments"`
+}
+
+func (p *PackResult) toJSON() packResultJSON {
+	return packResultJSON{
+		Version: packResultVersion,
+
+		MemoryBytes: p.Memory,
+		MemoryHuman: humanize.IBytes(uint64(p.Memory)),
+
+		Wtime: p.Wtime.String(),
+		Stime: p.Stime.String(),
+		Utime: p.Utime.String(),
+
+		FileSizeBytes: p.FileSize,
+		FileSizeHuman: humanize.IBytes(uint64(p.FileSize)),
+
+		Files:    len(p.Files),
+		Segments: totalSegments(p.Analyses),
+	}
+}
+
+// WriteJSON writes a versioned JSON document describing the result to w.
+func (p *PackResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p.toJSON())
+}
+
+// SaveJSON writes the JSON document for the result to path.
+func (p *PackResult) SaveJSON(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := p.WriteJSON(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Summary returns a short human-readable description of the result.
+func (p *PackResult) Summary() string {
+	return fmt.Sprintf(
+		"memory: %s, wall: %s, file size: %s (%d files, %d segments)",
+		humanize.IBytes(uint64(p.Memory)),
+		p.Wtime,
+		humanize.IBytes(uint64(p.FileSize)),
+		len(p.Files),
+		totalSegments(p.Analyses),
+	)
+}
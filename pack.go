@@ -1,29 +1,87 @@
 package borges
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/dustin/go-humanize"
+	"github.com/mattn/go-isatty"
+	"gopkg.in/src-d/regression-borges.v0/ui"
 	"gopkg.in/src-d/regression-core.v0"
 )
 
 type Pack struct {
-	*regression.Executor
-	test   bool
-	binary string
-	repo   string
-	files  []os.FileInfo
+	test     bool
+	binary   string
+	repo     string
+	files    []os.FileInfo
+	samples  []Sample
+	analyses []*FileAnalysis
+	progress ui.Progress
+
+	sampleInterval time.Duration
+
+	mu       sync.Mutex
+	proc     *os.Process
+	done     chan struct{}
+	killed   bool
+	executed bool
+	out      string
+	rusage   *syscall.Rusage
+	wall     time.Duration
 }
 
-func NewPack(binary, repo string) (*Pack, error) {
-	return &Pack{
-		Executor: new(regression.Executor),
-		binary:   binary,
-		repo:     repo,
-	}, nil
+// Option configures optional behavior of a Pack.
+type Option func(*Pack)
+
+// WithProgress attaches a progress reporter that receives periodic status
+// updates while the pack run is executing.
+func WithProgress(p ui.Progress) Option {
+	return func(pack *Pack) {
+		pack.progress = p
+	}
+}
+
+// WithSampleInterval overrides how often Run samples process metrics. The
+// default is defaultSampleInterval.
+func WithSampleInterval(d time.Duration) Option {
+	return func(pack *Pack) {
+		pack.sampleInterval = d
+	}
+}
+
+func NewPack(binary, repo string, opts ...Option) (*Pack, error) {
+	p := &Pack{
+		binary: binary,
+		repo:   repo,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p, nil
+}
+
+// pid returns the PID of the in-flight pack process, if any.
+func (p *Pack) pid() (int32, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc == nil {
+		return 0, false
+	}
+
+	return int32(p.proc.Pid), true
 }
 
 func (p *Pack) Run() error {
@@ -43,16 +101,60 @@ func (p *Pack) Run() error {
 	dArg := fmt.Sprintf("--root-repositories-dir=%s", dir)
 	tArg := fmt.Sprintf("--timeout=4h")
 
-	executor, err := regression.NewExecutor(p.binary, "pack", dArg, tArg, lArg)
-	if err != nil {
+	cmd := exec.Command(p.binary, "pack", dArg, tArg, lArg)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	start := time.Now()
+
+	if err := cmd.Start(); err != nil {
 		return err
 	}
 
-	p.Executor = executor
+	done := make(chan struct{})
+	p.mu.Lock()
+	p.proc = cmd.Process
+	p.done = done
+	alreadyKilled := p.killed
+	p.mu.Unlock()
 
-	err = p.Executor.Run()
-	if err != nil {
-		return err
+	if alreadyKilled {
+		p.Kill(killGrace)
+	}
+
+	s := newSampler(p.pid, p.sampleInterval)
+	s.start()
+
+	var progressDone chan struct{}
+	if p.progress != nil {
+		progressDone = make(chan struct{})
+		go p.runProgress(s, dir, start, progressDone)
+	}
+
+	runErr := cmd.Wait()
+	close(done)
+	p.samples = s.stop()
+
+	if progressDone != nil {
+		close(progressDone)
+		p.progress.Done()
+	}
+
+	p.mu.Lock()
+	p.executed = true
+	p.out = out.String()
+	if runErr == nil {
+		p.wall = time.Since(start)
+		if rusage, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+			p.rusage = rusage
+		}
+	}
+	p.mu.Unlock()
+
+	if runErr != nil {
+		return runErr
 	}
 
 	files, err := fileInfo(dir)
@@ -60,20 +162,67 @@ func (p *Pack) Run() error {
 		return err
 	}
 
+	analyses := make([]*FileAnalysis, 0, len(files))
+	for _, fi := range files {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != ".siva" {
+			continue
+		}
+
+		a, err := Analyze(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			log.Printf("pack: skipping structural analysis of %s: %s", fi.Name(), err)
+			continue
+		}
+
+		analyses = append(analyses, a)
+	}
+
 	p.files = files
+	p.analyses = analyses
 	p.test = true
 
 	return nil
 }
 
 func (p *Pack) Files() ([]os.FileInfo, error) {
-	if !p.Executed {
+	if !p.executed {
 		return nil, regression.ErrNotRun
 	}
 
 	return p.files, nil
 }
 
+// Out returns the combined stdout and stderr of the pack process.
+func (p *Pack) Out() (string, error) {
+	if !p.executed {
+		return "", regression.ErrNotRun
+	}
+
+	return p.out, nil
+}
+
+// Rusage returns resource usage data for the pack process.
+func (p *Pack) Rusage() (*syscall.Rusage, error) {
+	if !p.executed {
+		return nil, regression.ErrNotRun
+	}
+
+	if p.rusage == nil {
+		return nil, regression.ErrRusageNotAvailable
+	}
+
+	return p.rusage, nil
+}
+
+// Wall returns the wall-clock time taken by the pack process.
+func (p *Pack) Wall() (time.Duration, error) {
+	if !p.executed {
+		return 0, regression.ErrNotRun
+	}
+
+	return p.wall, nil
+}
+
 func (p *Pack) Result() (*PackResult, error) {
 	var size int64
 
@@ -98,6 +247,8 @@ func (p *Pack) Result() (*PackResult, error) {
 		Utime:    time.Duration(rusage.Utime.Nano()),
 		Files:    p.files,
 		FileSize: size,
+		Samples:  p.samples,
+		Analyses: p.analyses,
 	}
 
 	return packResult, nil
@@ -110,24 +261,31 @@ type PackResult struct {
 	Utime    time.Duration
 	Files    []os.FileInfo
 	FileSize int64 // bytes
+	Samples  []Sample
+	Analyses []*FileAnalysis
 }
 
 type PackComparison struct {
-	Memory   float64
-	Wtime    float64
-	Stime    float64
-	Utime    float64
-	FileSize float64
+	Memory       float64
+	Wtime        float64
+	Stime        float64
+	Utime        float64
+	FileSize     float64
+	SegmentDelta int
 }
 
 const (
-	Memory   = "memory"
-	Time     = "time"
-	FileSize = "file_size"
+	Memory           = "memory"
+	Time             = "time"
+	FileSize         = "file_size"
+	MemoryTimeseries = "memory_timeseries"
+	CPUTimeseries    = "cpu_timeseries"
+	IOTimeseries     = "io_timeseries"
+	Structure        = "structure"
 )
 
 func (p *PackResult) SaveAllCSV(prefix string) error {
-	for _, s := range []string{Memory, Time, FileSize} {
+	for _, s := range []string{Memory, Time, FileSize, MemoryTimeseries, CPUTimeseries, IOTimeseries, Structure} {
 		if err := p.SaveCSV(s, fmt.Sprintf("%s%s.csv", prefix, s)); err != nil {
 			return err
 		}
@@ -162,6 +320,47 @@ func (p *PackResult) WriteCSV(series string, w io.Writer) error {
 	case FileSize:
 		_, err := fmt.Fprintf(w, "%s\n%f\n", FileSize, toMiB(p.FileSize))
 		return err
+	case MemoryTimeseries:
+		if _, err := fmt.Fprintf(w, "T,RSS,VMS\n"); err != nil {
+			return err
+		}
+		for _, s := range p.Samples {
+			if _, err := fmt.Fprintf(w, "%f,%d,%d\n", s.T.Seconds(), s.RSS, s.VMS); err != nil {
+				return err
+			}
+		}
+		return nil
+	case CPUTimeseries:
+		if _, err := fmt.Fprintf(w, "T,CPUPercent\n"); err != nil {
+			return err
+		}
+		for _, s := range p.Samples {
+			if _, err := fmt.Fprintf(w, "%f,%f\n", s.T.Seconds(), s.CPUPercent); err != nil {
+				return err
+			}
+		}
+		return nil
+	case IOTimeseries:
+		if _, err := fmt.Fprintf(w, "T,ReadBytes,WriteBytes,OpenFDs\n"); err != nil {
+			return err
+		}
+		for _, s := range p.Samples {
+			if _, err := fmt.Fprintf(w, "%f,%d,%d,%d\n", s.T.Seconds(), s.ReadBytes, s.WriteBytes, s.OpenFDs); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Structure:
+		if _, err := fmt.Fprintf(w, "Path,Segments,IndexEntries,ObjectCount,UncompressedSize,CompressedSize\n"); err != nil {
+			return err
+		}
+		for _, a := range p.Analyses {
+			if _, err := fmt.Fprintf(w, "%s,%d,%d,%d,%d,%d\n",
+				a.Path, a.Segments, a.IndexEntries, a.ObjectCount, a.UncompressedSize, a.CompressedSize); err != nil {
+				return err
+			}
+		}
+		return nil
 	default:
 		return fmt.Errorf("unsupported series: %s", series)
 	}
@@ -169,78 +368,163 @@ func (p *PackResult) WriteCSV(series string, w io.Writer) error {
 
 func (p *PackResult) Compare(q *PackResult) PackComparison {
 	return PackComparison{
-		Memory:   percent(p.Memory, q.Memory),
-		Wtime:    percent(int64(p.Wtime), int64(q.Wtime)),
-		Stime:    percent(int64(p.Stime), int64(q.Stime)),
-		Utime:    percent(int64(p.Utime), int64(q.Utime)),
-		FileSize: percent(p.FileSize, q.FileSize),
+		Memory:       percent(p.Memory, q.Memory),
+		Wtime:        percent(int64(p.Wtime), int64(q.Wtime)),
+		Stime:        percent(int64(p.Stime), int64(q.Stime)),
+		Utime:        percent(int64(p.Utime), int64(q.Utime)),
+		FileSize:     percent(p.FileSize, q.FileSize),
+		SegmentDelta: totalSegments(q.Analyses) - totalSegments(p.Analyses),
 	}
 }
 
 const (
 	compareFormat = "%s: %v -> %v (%v), %v\n"
+
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorReset = "\x1b[0m"
 )
 
 func toMiB(i int64) float64 {
 	return float64(i) / float64(1024*1024)
 }
 
+// formatPercent renders a signed comparison percentage, colored green or red
+// when stdout is a TTY and left plain otherwise (e.g. when piped into CI
+// logs).
+func formatPercent(c float64, ok bool) string {
+	s := fmt.Sprintf("%+.2f%%", c)
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		return s
+	}
+
+	color := colorGreen
+	if !ok {
+		color = colorRed
+	}
+
+	return color + s + colorReset
+}
+
 func (p *PackResult) ComparePrint(q *PackResult, allowance float64) bool {
 	ok := true
 	c := p.Compare(q)
 
-	if c.Memory > allowance {
+	memoryOK := c.Memory <= allowance
+	if !memoryOK {
 		ok = false
 	}
 	fmt.Printf(compareFormat,
 		"Memory",
-		toMiB(p.Memory),
-		toMiB(q.Memory),
-		c.Memory,
-		allowance > c.Memory,
+		humanize.IBytes(uint64(p.Memory)),
+		humanize.IBytes(uint64(q.Memory)),
+		formatPercent(c.Memory, memoryOK),
+		memoryOK,
 	)
 
-	if c.Wtime > allowance {
+	wtimeOK := c.Wtime <= allowance
+	if !wtimeOK {
 		ok = false
 	}
 	fmt.Printf(compareFormat,
 		"Wtime",
 		p.Wtime,
 		q.Wtime,
-		c.Wtime,
-		allowance > c.Wtime,
+		formatPercent(c.Wtime, wtimeOK),
+		wtimeOK,
 	)
 
 	fmt.Printf(compareFormat,
 		"Stime",
 		p.Stime,
 		q.Stime,
-		c.Stime,
-		allowance > c.Stime,
+		formatPercent(c.Stime, c.Stime <= allowance),
+		c.Stime <= allowance,
 	)
 
 	fmt.Printf(compareFormat,
 		"Utime",
 		p.Utime,
 		q.Utime,
-		c.Utime,
-		allowance > c.Utime,
+		formatPercent(c.Utime, c.Utime <= allowance),
+		c.Utime <= allowance,
 	)
 
-	if c.FileSize > allowance {
+	fileSizeOK := c.FileSize <= allowance
+	if !fileSizeOK {
 		ok = false
 	}
 	fmt.Printf(compareFormat,
 		"FileSize",
-		toMiB(p.FileSize),
-		toMiB(q.FileSize),
-		c.FileSize,
-		allowance > c.FileSize,
+		humanize.IBytes(uint64(p.FileSize)),
+		humanize.IBytes(uint64(q.FileSize)),
+		formatPercent(c.FileSize, fileSizeOK),
+		fileSizeOK,
+	)
+
+	fmt.Printf("%s: %v -> %v (%+d)\n",
+		"Segments",
+		totalSegments(p.Analyses),
+		totalSegments(q.Analyses),
+		c.SegmentDelta,
 	)
 
 	return ok
 }
 
+const (
+	progressInterval     = 5 * time.Second
+	expectedPackDuration = 4 * time.Hour
+)
+
+// runProgress periodically reports status to p.progress until done is
+// closed: elapsed wall time, the most recent RSS sample, and the bytes
+// written under dir so far, with an ETA based on the expected pack duration.
+func (p *Pack) runProgress(s *sampler, dir string, start time.Time, done <-chan struct{}) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			elapsed := time.Since(start)
+
+			bytes, err := dirSize(dir)
+			if err != nil {
+				continue
+			}
+
+			last := s.last()
+			p.progress.Update(ui.Status{
+				Elapsed: elapsed,
+				RSS:     int64(last.RSS),
+				Bytes:   bytes,
+				ETA:     expectedPackDuration - elapsed,
+			})
+		}
+	}
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
+
 func createList(repo string) (string, error) {
 	tmpFile, err := ioutil.TempFile("", "packer-list")
 	if err != nil {
@@ -0,0 +1,148 @@
+package borges
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// Sample is a point-in-time measurement of a running pack process.
+type Sample struct {
+	T          time.Duration
+	RSS        uint64
+	VMS        uint64
+	CPUPercent float64
+	ReadBytes  uint64
+	WriteBytes uint64
+	OpenFDs    int32
+}
+
+const defaultSampleInterval = time.Second
+
+// sampler polls a child process at a fixed interval until stopped, degrading
+// gracefully: it logs and stops sampling if it can't attach to the process,
+// and logs and skips any single sample that can't be read.
+type sampler struct {
+	pidFunc  func() (int32, bool)
+	interval time.Duration
+
+	mu      sync.Mutex
+	samples []Sample
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newSampler(pidFunc func() (int32, bool), interval time.Duration) *sampler {
+	if interval <= 0 {
+		interval = defaultSampleInterval
+	}
+
+	return &sampler{
+		pidFunc:  pidFunc,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *sampler) start() {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		start := time.Now()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		var proc *process.Process
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if proc == nil {
+					pid, ok := s.pidFunc()
+					if !ok {
+						continue
+					}
+
+					p, err := process.NewProcess(pid)
+					if err != nil {
+						log.Printf("sampler: cannot attach to pid %d, disabling sampling: %s", pid, err)
+						return
+					}
+					proc = p
+				}
+
+				sample, err := sampleProcess(proc, time.Since(start))
+				if err != nil {
+					log.Printf("sampler: skipping sample: %s", err)
+					continue
+				}
+
+				s.mu.Lock()
+				s.samples = append(s.samples, sample)
+				s.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// stop halts sampling and returns the samples collected so far.
+func (s *sampler) stop() []Sample {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.samples
+}
+
+// last returns the most recent sample, or the zero Sample if none exist yet.
+func (s *sampler) last() Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.samples) == 0 {
+		return Sample{}
+	}
+
+	return s.samples[len(s.samples)-1]
+}
+
+func sampleProcess(proc *process.Process, t time.Duration) (Sample, error) {
+	mem, err := proc.MemoryInfo()
+	if err != nil {
+		return Sample{}, fmt.Errorf("memory info: %s", err)
+	}
+
+	cpu, err := proc.CPUPercent()
+	if err != nil {
+		return Sample{}, fmt.Errorf("cpu percent: %s", err)
+	}
+
+	var readBytes, writeBytes uint64
+	if io, err := proc.IOCounters(); err == nil {
+		readBytes = io.ReadBytes
+		writeBytes = io.WriteBytes
+	}
+
+	var fds int32
+	if n, err := proc.NumFDs(); err == nil {
+		fds = n
+	}
+
+	return Sample{
+		T:          t,
+		RSS:        mem.RSS,
+		VMS:        mem.VMS,
+		CPUPercent: cpu,
+		ReadBytes:  readBytes,
+		WriteBytes: writeBytes,
+		OpenFDs:    fds,
+	}, nil
+}
@@ -0,0 +1,78 @@
+// Package ui provides progress reporting for long-running pack runs.
+package ui
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Status is a snapshot of a pack run's progress at a point in time.
+type Status struct {
+	Elapsed time.Duration
+	RSS     int64
+	Bytes   int64
+	ETA     time.Duration
+}
+
+// Progress receives periodic status updates while a pack run is in
+// progress.
+type Progress interface {
+	// Update is called with the latest status at every reporting interval.
+	Update(Status)
+	// Done is called once, after the pack run has finished.
+	Done()
+}
+
+// Plain is a Progress that logs one line per update, suitable for CI logs
+// where redrawing the terminal isn't possible.
+type Plain struct {
+	w io.Writer
+}
+
+// NewPlain creates a Plain progress reporter writing to w.
+func NewPlain(w io.Writer) *Plain {
+	return &Plain{w: w}
+}
+
+func (p *Plain) Update(s Status) {
+	fmt.Fprintf(p.w, "elapsed=%s rss=%d bytes=%d eta=%s\n",
+		s.Elapsed.Round(time.Second), s.RSS, s.Bytes, s.ETA.Round(time.Second))
+}
+
+func (p *Plain) Done() {}
+
+// TermStatus is a Progress that redraws a multi-line status block in place
+// using ANSI cursor-up codes. It is only suitable for a TTY.
+type TermStatus struct {
+	w     io.Writer
+	lines int
+}
+
+// NewTermStatus creates a TermStatus progress reporter writing to w.
+func NewTermStatus(w io.Writer) *TermStatus {
+	return &TermStatus{w: w}
+}
+
+func (t *TermStatus) Update(s Status) {
+	if t.lines > 0 {
+		fmt.Fprintf(t.w, "\x1b[%dA", t.lines)
+	}
+
+	rows := []string{
+		fmt.Sprintf("elapsed: %s", s.Elapsed.Round(time.Second)),
+		fmt.Sprintf("rss:     %d bytes", s.RSS),
+		fmt.Sprintf("written: %d bytes", s.Bytes),
+		fmt.Sprintf("eta:     %s", s.ETA.Round(time.Second)),
+	}
+
+	for _, row := range rows {
+		fmt.Fprintf(t.w, "\x1b[2K%s\n", row)
+	}
+
+	t.lines = len(rows)
+}
+
+func (t *TermStatus) Done() {
+	t.lines = 0
+}